@@ -0,0 +1,119 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+
+// bzzd is the swarm daemon: it registers the swarm stack as a node.Service
+// and serves the bzz_* RPC methods over IPC so that bzzup, bzzhash and the
+// fuse mount command can talk to a long running node.
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/ethereum/go-ethereum/bzz"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+var (
+	app = utils.NewApp("", "the swarm daemon")
+
+	bzzPortFlag = cli.IntFlag{
+		Name:  "bzzport",
+		Usage: "swarm port",
+		Value: 8500,
+	}
+	bzzAccountFlag = cli.StringFlag{
+		Name:  "bzzaccount",
+		Usage: "swarm account key used to register the local node",
+	}
+)
+
+func init() {
+	app.Action = run
+	app.Flags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.IPCPathFlag,
+		bzzPortFlag,
+		bzzAccountFlag,
+	}
+	app.Commands = []cli.Command{
+		mountCommand,
+	}
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run boots a node.Node, registers the swarm service into it and keeps the
+// node's IPC endpoint alive so that the bzz_* RPC methods are reachable.
+func run(ctx *cli.Context) {
+	key := bzzKey(ctx)
+	stack, err := node.New(&node.Config{
+		Name:       "bzzd",
+		PrivateKey: key,
+		DataDir:    ctx.GlobalString(utils.DataDirFlag.Name),
+		IPCPath:    ctx.GlobalString(utils.IPCPathFlag.Name),
+	})
+	if err != nil {
+		utils.Fatalf("create node: %v", err)
+	}
+
+	var swarm *bzz.Swarm
+	if err := stack.Register(func(sctx *node.ServiceContext) (node.Service, error) {
+		var err error
+		swarm, err = bzz.NewSwarm(key, bzz.Config{
+			Port: ctx.Int(bzzPortFlag.Name),
+		})
+		return swarm, err
+	}); err != nil {
+		utils.Fatalf("register swarm service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		utils.Fatalf("start node: %v", err)
+	}
+	defer stack.Stop()
+
+	glog.V(logger.Info).Infof("bzzd listening on IPC %s, swarm port %d", stack.IPCEndpoint(), ctx.Int(bzzPortFlag.Name))
+	utils.WaitForInterrupt()
+}
+
+// bzzKey loads the account given by --bzzaccount, falling back to an
+// ephemeral key so bzzd can also run as a relay-only node.
+func bzzKey(ctx *cli.Context) *ecdsa.PrivateKey {
+	account := ctx.String(bzzAccountFlag.Name)
+	if account == "" {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			utils.Fatalf("generate swarm key: %v", err)
+		}
+		return key
+	}
+	key, err := utils.LoadAccountKey(ctx.GlobalString(utils.DataDirFlag.Name), account)
+	if err != nil {
+		utils.Fatalf("load bzzaccount %s: %v", account, err)
+	}
+	return key
+}
@@ -0,0 +1,291 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+	"golang.org/x/net/context"
+
+	"github.com/codegangsta/cli"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/rpc/api"
+	"github.com/ethereum/go-ethereum/rpc/client"
+)
+
+// manifestContentType is what bzz_get's contentType comes back as for a
+// path that resolves to a manifest (i.e. a directory) rather than a leaf
+// file's own content.
+const manifestContentType = "application/bzz-manifest+json"
+
+var mountCommand = cli.Command{
+	Action:    mount,
+	Name:      "mount",
+	Usage:     "mount a bzz manifest hash as a local, read/write filesystem",
+	ArgsUsage: "<manifest hash> <mountpoint>",
+	Description: `
+Mount exposes a bzz manifest as a FUSE filesystem rooted at mountpoint.
+Reads are served through bzz_get and writes are flushed back into swarm,
+and a new manifest hash, through bzz_modify; it requires a running bzzd
+reachable on the IPC endpoint given by --ipcpath.
+`,
+}
+
+func mount(ctx *cli.Context) {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("usage: bzzd mount <manifest hash> <mountpoint>")
+	}
+	hash, mountpoint := ctx.Args()[0], ctx.Args()[1]
+
+	rpcClient, err := client.NewIpcClient(ctx.GlobalString(utils.IPCPathFlag.Name))
+	if err != nil {
+		utils.Fatalf("dial bzzd IPC: %v", err)
+	}
+
+	conn, err := fuse.Mount(mountpoint)
+	if err != nil {
+		utils.Fatalf("fuse mount %s: %v", mountpoint, err)
+	}
+	defer conn.Close()
+
+	filesystem := &swarmfs{root: hash, client: rpcClient}
+	go func() {
+		if err := fusefs.Serve(conn, filesystem); err != nil {
+			glog.V(logger.Error).Infof("fuse serve: %v", err)
+		}
+	}()
+
+	// Unmount cleanly on Ctrl-C so the final manifest hash is flushed.
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	<-sigc
+	fuse.Unmount(mountpoint)
+}
+
+// swarmfs is a bazil.org/fuse root that lazily resolves manifest entries
+// through the RPC client's bzz_get/bzz_modify methods, so directory
+// listings and file reads never need the whole manifest in memory. root is
+// the manifest hash writes are resolved against; it advances every time a
+// file is flushed back into swarm, so lock guards it against concurrent
+// writers.
+type swarmfs struct {
+	client *client.IpcClient
+
+	lock sync.Mutex
+	root string
+}
+
+func (fs *swarmfs) Root() (fusefs.Node, error) {
+	return &swarmDir{fs: fs, path: ""}, nil
+}
+
+// getResult mirrors the object bzzApi.Get returns: the leaf's raw bytes (or,
+// for a directory, its manifest listing) along with the content type that
+// says which one it is.
+type getResult struct {
+	Content     string `json:"content"`
+	ContentType string `json:"contentType"`
+	Status      string `json:"status"`
+	Size        string `json:"size"`
+}
+
+// get resolves path against fs's current root via bzz_get. If listing is
+// set, a trailing slash is appended after joining so swarm resolves path
+// as a directory's manifest listing rather than a leaf's own content;
+// path.Join alone would silently Clean that slash away.
+func (fs *swarmfs) get(p string, listing bool) (*getResult, error) {
+	fs.lock.Lock()
+	root := fs.root
+	fs.lock.Unlock()
+
+	joined := path.Join(root, p)
+	if listing {
+		joined += "/"
+	}
+	res := new(getResult)
+	if err := fs.client.Call(res, "bzz_get", &api.BzzGetArgs{Path: joined}); err != nil {
+		return nil, err
+	}
+	if status, err := strconv.Atoi(res.Status); err == nil && status >= 400 {
+		return nil, fuse.ENOENT
+	}
+	return res, nil
+}
+
+// put writes content into swarm and folds the resulting hash into fs's
+// manifest at path, advancing fs.root to the new manifest hash bzz_modify
+// returns.
+func (fs *swarmfs) put(p, contentType string, content []byte) error {
+	var contentHash string
+	if err := fs.client.Call(&contentHash, "bzz_put", &api.BzzPutArgs{Content: string(content), ContenType: contentType}); err != nil {
+		return err
+	}
+
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	var newRoot string
+	err := fs.client.Call(&newRoot, "bzz_modify", &api.BzzModifyArgs{
+		RootHash:    fs.root,
+		Path:        p,
+		ContentHash: contentHash,
+		ContentType: contentType,
+	})
+	if err != nil {
+		return err
+	}
+	fs.root = newRoot
+	return nil
+}
+
+// swarmDir resolves its children on demand via bzz_get on (root, path).
+// It has no write methods of its own: writes to files beneath it are
+// persisted by swarmFile.Flush through bzz_modify, which updates fs.root.
+type swarmDir struct {
+	fs   *swarmfs
+	path string
+}
+
+func (d *swarmDir) Attr(a *fuse.Attr) error {
+	a.Mode = os.ModeDir | 0555
+	return nil
+}
+
+// ReadDirAll lists the manifest entries directly under d.path by asking
+// swarm to resolve it, expecting the manifest-listing content type back
+// rather than a leaf file's own bytes.
+func (d *swarmDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	res, err := d.fs.get(d.path, true)
+	if err != nil {
+		return nil, err
+	}
+	if res.ContentType != manifestContentType {
+		return nil, fuse.Errno(fuse.ENOTDIR)
+	}
+
+	seen := make(map[string]bool)
+	var entries []fuse.Dirent
+	for _, entry := range strings.Split(res.Content, "\n") {
+		name := strings.TrimPrefix(entry, d.path+"/")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		typ := fuse.DT_File
+		if strings.HasSuffix(entry, "/") {
+			typ = fuse.DT_Dir
+			name = strings.TrimSuffix(name, "/")
+		}
+		entries = append(entries, fuse.Dirent{Name: name, Type: typ})
+	}
+	return entries, nil
+}
+
+// Lookup resolves name under d.path, returning a swarmDir if it is itself a
+// manifest (a sub-directory) or a swarmFile otherwise.
+func (d *swarmDir) Lookup(ctx context.Context, name string) (fusefs.Node, error) {
+	childPath := path.Join(d.path, name)
+	res, err := d.fs.get(childPath, false)
+	if err != nil {
+		return nil, err
+	}
+	if res.ContentType == manifestContentType {
+		return &swarmDir{fs: d.fs, path: childPath}, nil
+	}
+	return &swarmFile{fs: d.fs, path: childPath, contentType: res.ContentType}, nil
+}
+
+// swarmFile is a single manifest leaf. Reads are served straight from
+// bzz_get; writes are buffered locally and only flushed back into swarm
+// (via bzz_put + bzz_modify) on Flush, so a series of small Write calls
+// from the kernel doesn't turn into a series of new manifest roots.
+type swarmFile struct {
+	fs          *swarmfs
+	path        string
+	contentType string
+
+	lock    sync.Mutex
+	pending []byte
+	dirty   bool
+}
+
+func (f *swarmFile) Attr(a *fuse.Attr) error {
+	a.Mode = 0644
+	return nil
+}
+
+func (f *swarmFile) ReadAll(ctx context.Context) ([]byte, error) {
+	f.lock.Lock()
+	if f.dirty {
+		content := make([]byte, len(f.pending))
+		copy(content, f.pending)
+		f.lock.Unlock()
+		return content, nil
+	}
+	f.lock.Unlock()
+
+	res, err := f.fs.get(f.path, false)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(res.Content), nil
+}
+
+func (f *swarmFile) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	end := int(req.Offset) + len(req.Data)
+	if end > len(f.pending) {
+		grown := make([]byte, end)
+		copy(grown, f.pending)
+		f.pending = grown
+	}
+	copy(f.pending[req.Offset:], req.Data)
+	f.dirty = true
+	resp.Size = len(req.Data)
+	return nil
+}
+
+// Flush persists whatever has been Written since the file was opened (or
+// the last Flush) back into swarm, advancing fs.root to the new manifest.
+func (f *swarmFile) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	f.lock.Lock()
+	dirty := f.dirty
+	var content []byte
+	if dirty {
+		content = make([]byte, len(f.pending))
+		copy(content, f.pending)
+	}
+	f.dirty = false
+	f.lock.Unlock()
+
+	if !dirty {
+		return nil
+	}
+	return f.fs.put(f.path, f.contentType, content)
+}
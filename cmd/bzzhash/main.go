@@ -0,0 +1,57 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+
+// bzzhash computes the swarm root hash of a local file without touching the
+// network: it feeds the file straight into the chunker that bzzd would use
+// on upload and prints the root hash of the file's chunk tree. This is not
+// the manifest hash bzz_upload returns, which wraps the content in a
+// manifest.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/bzz/storage"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: bzzhash <file>")
+		os.Exit(1)
+	}
+
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stat %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+
+	chunker := storage.NewTreeChunker(storage.NewChunkerParams())
+	key, err := chunker.Split(f, stat.Size(), nil, nil, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hash %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+	fmt.Printf("%x\n", key)
+}
@@ -0,0 +1,75 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+
+// bzzup recursively uploads a local file or directory into swarm through a
+// running bzzd's bzz_upload RPC method and prints the resulting manifest
+// hash.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/rpc/api"
+	"github.com/ethereum/go-ethereum/rpc/client"
+)
+
+var app = utils.NewApp("", "upload a file or directory to swarm")
+
+var defaultPathFlag = cli.StringFlag{
+	Name:  "defaultpath",
+	Usage: "path within the uploaded directory to serve when no path is given",
+}
+
+func init() {
+	app.Flags = []cli.Flag{
+		utils.IPCPathFlag,
+		defaultPathFlag,
+	}
+	app.Action = upload
+	app.ArgsUsage = "<file or directory>"
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func upload(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("usage: bzzup [options] <file or directory>")
+	}
+	localPath := ctx.Args()[0]
+
+	rpcClient, err := client.NewIpcClient(ctx.GlobalString(utils.IPCPathFlag.Name))
+	if err != nil {
+		utils.Fatalf("dial bzzd IPC: %v", err)
+	}
+
+	var hash string
+	err = rpcClient.Call(&hash, "bzz_upload", &api.BzzUploadArgs{
+		LocalPath: localPath,
+		Index:     ctx.String(defaultPathFlag.Name),
+	})
+	if err != nil {
+		utils.Fatalf("upload %s: %v", localPath, err)
+	}
+	fmt.Println(hash)
+}
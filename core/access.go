@@ -1,115 +1,200 @@
 package core
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // Access is the instance on which lazy accessors (core/chain_util)
-// allowing ODR should be called
-// GetBody, GetBlock, GetBlockRLP all fall back to only GetBodyRLP
+// allowing ODR should be called.
+//
+// GetBody, GetBlock, GetBlockReceipts, GetNodeData and GetTrieEntry all fall
+// back to odr when the local database has no answer, and persist whatever
+// odr returns so the next call is served locally.
 type Access struct {
-	GetBodyRLP       func(db ethdb.Database, hash common.Hash) rlp.RawValue
-	GetBlockReceipts func(db ethdb.Database, hash common.Hash) types.Receipts
-	// GetNodeData?
-	// GetTrieEntry?
+	odr OdrBackend
 }
 
-// Access type using no ODR just local DB simply sets GetBlockRLP, GetBlockReceipts
-// functions to the ones in core/chain_util
+// NewLocalAccess returns an Access that never leaves the local database; any
+// miss is reported as not found, matching a full node's behaviour.
 func NewLocalAccess() *Access {
-	return &Access{
-		GetBodyRLP:       GetBodyRLP,
-		GetBlockReceipts: GetBlockReceipts,
-	}
+	return &Access{}
+}
+
+// NewFallBackAccess returns an Access that retrieves whatever the local
+// database is missing through odr, verifies it against the requested
+// hash/root and writes it back to db before returning it. This keeps all the
+// DbGet/DbPut bookkeeping in one place instead of duplicating it at every
+// call site.
+func NewFallBackAccess(odr OdrBackend) *Access {
+	return &Access{odr: odr}
 }
 
-// this creates an Access instance that uses the these accessor functions
-// only as a fallback if local access gives no result
-// in case the fallback call is needed and succeeds, the result is written
-// to local Db
-// This simplifies all the DbGet/DbPut logic
-// This fallback logic can be directly implemented in core/odr.Odr functions
-// and does not need to be implemented here
-func NewFallBackAccess(
-	getBodyRLP func(db ethdb.Database, hash common.Hash) rlp.RawValue,
-	getRawBlockReceipts func(db ethdb.Database, hash common.Hash) []byte,
-) *Access {
-	return &Access{
-		GetBodyRLP: func(db ethdb.Database, hash common.Hash) rlp.RawValue {
-			data := GetBodyRLP(db, hash)
-			if data == nil {
-				data := getBodyRLP(db, hash)
-				if data != nil {
-					WriteBodyRLP(db, hash, data)
-				}
-			}
-			return data
-		},
-		GetRawBlockReceipts: func(db ethdb.Database, hash common.Hash) [] {
-			data := GetBlockReceipts(db, hash)
-			if len(data) == 0 {
-				data := getBlockReceipts(db, hash)
-				if len(data) > 0 {
-					WriteBlockReceipts(db, hash, data)
-					WriteReceipts(db, hash, data)
-				}
-			}
-			return data
-		},
+// retrieve issues req against odr and blocks until it is served or ctx is
+// cancelled. It is a no-op (returning ErrNoOdr) when the Access was built
+// with NewLocalAccess.
+func (self *Access) retrieve(ctx context.Context, req OdrRequest) error {
+	if self.odr == nil {
+		return ErrNoOdr
 	}
+	return self.odr.Retrieve(ctx, req)
 }
 
-// GetBody retrieves the block body (transactons, uncles) corresponding to the
-// hash, nil if none found.
-func (self *Access) GetBody(db ethdb.Database, hash common.Hash) *types.Body {
+// GetBody retrieves the block body (transactions, uncles) corresponding to
+// the hash, nil if none found. A body fetched through odr is verified
+// against the block's header (transaction root, uncle hash) before it is
+// trusted and persisted.
+func (self *Access) GetBody(ctx context.Context, db ethdb.Database, hash common.Hash) (*types.Body, error) {
 	data := GetBodyRLP(db, hash)
 	if len(data) == 0 {
-		return nil
+		header := GetHeader(db, hash)
+		if header == nil {
+			return nil, ErrNoHeader
+		}
+		req := &BlockRequest{Hash: hash, Header: header}
+		if err := self.retrieve(ctx, req); err != nil {
+			return nil, err
+		}
+		if !req.Valid() {
+			return nil, ErrInvalidResponse
+		}
+		data = req.Rlp
+		WriteBodyRLP(db, hash, data)
 	}
 	body := new(types.Body)
 	if err := rlp.Decode(bytes.NewReader(data), body); err != nil {
 		glog.V(logger.Error).Infof("invalid block body RLP for hash %x: %v", hash, err)
-		return nil
+		return nil, err
 	}
-	return body
-}
-
-func (self *Access) GetRawBlockReceipts(db ethdb.Database, hash common.Hash) []byte {
-	data, _ := db.Get(append(blockReceiptsPrefix, hash[:]...))
-	return data
+	return body, nil
 }
 
-// GetBlock retrieves an entire block corresponding to the hash, assembling it
-// back from the stored header and body.
-func (self *Access) GetBlock(db ethdb.Database, hash common.Hash) *types.Block {
-	// Retrieve the block header and body contents
+// GetBlock retrieves an entire block corresponding to the hash, assembling
+// it back from the stored header and body.
+func (self *Access) GetBlock(ctx context.Context, db ethdb.Database, hash common.Hash) (*types.Block, error) {
 	header := GetHeader(db, hash)
 	if header == nil {
-		return nil
+		return nil, ErrNoHeader
 	}
-	body := GetBody(db, hash)
-	if body == nil {
-		return nil
+	body, err := self.GetBody(ctx, db, hash)
+	if err != nil {
+		return nil, err
 	}
-	// Reassemble the block and return
-	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
+	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles), nil
 }
 
-// GetBlockReceipts retrieves the receipts generated by the transactions included
-// in a block given by its hash.
-func (self *Access) GetBlockReceipts(db ethdb.Database, hash common.Hash) types.Receipts {
-	receipts := self.GetRawBlockReceipts(db, hash)
-	if receipts == nil {
-		return nil
+// GetBlockReceipts retrieves the receipts generated by the transactions
+// included in a block given by its hash, verifying them against the block's
+// receipt root before handing them back when they were fetched through odr.
+func (self *Access) GetBlockReceipts(ctx context.Context, db ethdb.Database, hash common.Hash) (types.Receipts, error) {
+	data, _ := db.Get(append(blockReceiptsPrefix, hash[:]...))
+	if len(data) == 0 {
+		header := GetHeader(db, hash)
+		if header == nil {
+			return nil, ErrNoHeader
+		}
+		req := &ReceiptsRequest{Hash: hash, Header: header}
+		if err := self.retrieve(ctx, req); err != nil {
+			return nil, err
+		}
+		if !req.Valid() {
+			return nil, ErrInvalidResponse
+		}
+		data = req.Rlp
+		WriteBlockReceipts(db, hash, data)
 	}
 	storageReceipts := []*types.ReceiptForStorage{}
 	if err := rlp.DecodeBytes(data, &storageReceipts); err != nil {
 		glog.V(logger.Error).Infof("invalid receipt array RLP for hash %x: %v", hash, err)
-		return nil
+		return nil, err
 	}
 	receipts := make(types.Receipts, len(storageReceipts))
 	for i, receipt := range storageReceipts {
 		receipts[i] = (*types.Receipt)(receipt)
 	}
-	return receipts
+	return receipts, nil
+}
+
+// GetNodeData retrieves a single piece of content-addressed data (a raw
+// trie node or a contract's bytecode, both of which the state database
+// keys by their own hash) falling back to odr when it isn't stored
+// locally. The returned data's own hash is checked against hash before it
+// is trusted and written to db.
+func (self *Access) GetNodeData(ctx context.Context, db ethdb.Database, hash common.Hash) ([]byte, error) {
+	data, _ := db.Get(hash[:])
+	if len(data) > 0 {
+		return data, nil
+	}
+	req := &CodeRequest{Hash: hash}
+	if err := self.retrieve(ctx, req); err != nil {
+		return nil, err
+	}
+	if crypto.Keccak256Hash(req.Code) != hash {
+		return nil, ErrInvalidResponse
+	}
+	db.Put(hash[:], req.Code)
+	return req.Code, nil
+}
+
+// GetTrieEntry retrieves the trie node identified by nodeHash in the trie
+// rooted at root, falling back to odr when it isn't stored locally. It is
+// the single-node convenience wrapper around GetTrieEntries, which should
+// be preferred when a caller (e.g. light.Trie resolving a proof) knows up
+// front that it needs more than one node.
+func (self *Access) GetTrieEntry(ctx context.Context, db ethdb.Database, root common.Hash, nodeHash []byte) ([]byte, error) {
+	nodes, err := self.GetTrieEntries(ctx, db, root, [][]byte{nodeHash})
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// GetTrieEntries batches a trie-node lookup for several node hashes under
+// the same root, falling back to odr in a single round-trip for whichever
+// nodes aren't already stored locally. Each node odr returns has its own
+// hash checked against the hash it was requested with before it is
+// trusted and written to db.
+func (self *Access) GetTrieEntries(ctx context.Context, db ethdb.Database, root common.Hash, nodeHashes [][]byte) ([][]byte, error) {
+	nodes := make([][]byte, len(nodeHashes))
+	var missing [][]byte
+	var missingIdx []int
+	for i, nodeHash := range nodeHashes {
+		if data, _ := db.Get(nodeHash); len(data) > 0 {
+			nodes[i] = data
+			continue
+		}
+		missing = append(missing, nodeHash)
+		missingIdx = append(missingIdx, i)
+	}
+	if len(missing) == 0 {
+		return nodes, nil
+	}
+
+	req := &TrieRequest{Root: root, Keys: missing}
+	if err := self.retrieve(ctx, req); err != nil {
+		return nil, err
+	}
+	for j, node := range req.Nodes {
+		if !bytes.Equal(crypto.Keccak256(node), missing[j]) {
+			return nil, ErrInvalidResponse
+		}
+		WriteTrieNode(db, node)
+		nodes[missingIdx[j]] = node
+	}
+	return nodes, nil
+}
+
+// WriteTrieNode persists a single trie node into db, keyed by its own
+// keccak256 hash, matching the way the trie package addresses nodes in
+// the database.
+func WriteTrieNode(db ethdb.Database, node []byte) error {
+	return db.Put(crypto.Keccak256(node), node)
 }
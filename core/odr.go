@@ -0,0 +1,130 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ErrNoOdr is returned by Access when it has no OdrBackend to fall back on
+// and the local database does not have the requested data.
+var ErrNoOdr = errors.New("no odr backend and local data missing")
+
+// ErrNoHeader is returned when an Access lookup needs a header (to know
+// what to verify a response against) that the local database doesn't have.
+var ErrNoHeader = errors.New("header not found locally")
+
+// ErrInvalidResponse is returned when an OdrBackend reports success but the
+// data it attached does not actually hash-check against what was asked
+// for; Access refuses to persist or return it.
+var ErrInvalidResponse = errors.New("odr response failed verification")
+
+// OdrBackend multiplexes OdrRequests to a set of remote peers capable of
+// answering them. Implementations live outside core (see package light) so
+// that core itself never needs to know about p2p peers or wire protocols.
+type OdrBackend interface {
+	// Retrieve blocks until req is served, ctx is cancelled, or every
+	// candidate peer has been exhausted. On success req is populated with
+	// the response data (e.g. req.(*BlockRequest).Rlp).
+	Retrieve(ctx context.Context, req OdrRequest) error
+}
+
+// OdrRequest is implemented by every request type Access can issue. Valid
+// reports whether the data an OdrBackend attached to the request actually
+// matches what was asked for, so a backend can discard bad peer responses
+// without the caller re-implementing the check. Every implementation here
+// performs a real cryptographic check against the field the request was
+// built with (a header, a hash) rather than a bare non-empty check, since
+// the whole point of odr is to not have to trust the peer that answered.
+type OdrRequest interface {
+	Valid() bool
+}
+
+// BlockRequest asks for the RLP-encoded body of the block with the given
+// hash; Rlp is filled in by the OdrBackend on success. Header must be set
+// by the caller so Valid can check Rlp's transactions/uncles against the
+// header's TxHash/UncleHash before it is trusted.
+type BlockRequest struct {
+	Hash   common.Hash
+	Header *types.Header
+	Rlp    []byte
+}
+
+func (r *BlockRequest) Valid() bool {
+	if len(r.Rlp) == 0 || r.Header == nil {
+		return false
+	}
+	body := new(types.Body)
+	if err := rlp.DecodeBytes(r.Rlp, body); err != nil {
+		return false
+	}
+	if types.DeriveSha(types.Transactions(body.Transactions)) != r.Header.TxHash {
+		return false
+	}
+	if types.CalcUncleHash(body.Uncles) != r.Header.UncleHash {
+		return false
+	}
+	return true
+}
+
+// ReceiptsRequest asks for the RLP-encoded receipts of the block with the
+// given hash; Header is supplied by the caller so Valid can check the
+// response against the block's receipt root before it is trusted.
+type ReceiptsRequest struct {
+	Hash   common.Hash
+	Header *types.Header
+	Rlp    []byte
+}
+
+func (r *ReceiptsRequest) Valid() bool {
+	if len(r.Rlp) == 0 || r.Header == nil {
+		return false
+	}
+	storageReceipts := []*types.ReceiptForStorage{}
+	if err := rlp.DecodeBytes(r.Rlp, &storageReceipts); err != nil {
+		return false
+	}
+	receipts := make(types.Receipts, len(storageReceipts))
+	for i, receipt := range storageReceipts {
+		receipts[i] = (*types.Receipt)(receipt)
+	}
+	return types.DeriveSha(receipts) == r.Header.ReceiptHash
+}
+
+// TrieRequest asks for one or more trie nodes by their own hash, so a
+// single proof round-trip can carry every node a light.Trie needs instead
+// of one request per missing node. Nodes is filled in parallel with Keys:
+// Nodes[i] is the node whose keccak256 hash is Keys[i], matching the way
+// the trie package addresses nodes in the database.
+type TrieRequest struct {
+	Root  common.Hash
+	Keys  [][]byte
+	Nodes [][]byte
+}
+
+func (r *TrieRequest) Valid() bool {
+	if len(r.Nodes) == 0 || len(r.Nodes) != len(r.Keys) {
+		return false
+	}
+	for i, node := range r.Nodes {
+		if len(node) == 0 || !bytes.Equal(crypto.Keccak256(node), r.Keys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CodeRequest asks for the contract code stored at the given code hash.
+type CodeRequest struct {
+	Hash common.Hash
+	Code []byte
+}
+
+func (r *CodeRequest) Valid() bool {
+	return len(r.Code) > 0 && crypto.Keccak256Hash(r.Code) == r.Hash
+}
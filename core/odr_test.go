@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestBlockRequestValid(t *testing.T) {
+	body := &types.Body{}
+	rlpBody, err := rlp.EncodeToBytes(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{
+		TxHash:    types.DeriveSha(types.Transactions(body.Transactions)),
+		UncleHash: types.CalcUncleHash(body.Uncles),
+	}
+
+	req := &BlockRequest{Header: header, Rlp: rlpBody}
+	if !req.Valid() {
+		t.Fatal("expected a body matching the header to be valid")
+	}
+
+	tampered := &BlockRequest{Header: header, Rlp: append(bytes.Repeat([]byte{0}, len(rlpBody)), 1)}
+	if tampered.Valid() {
+		t.Fatal("expected a body not matching the header to be rejected")
+	}
+}
+
+func TestReceiptsRequestValid(t *testing.T) {
+	receipts := []*types.ReceiptForStorage{}
+	rlpReceipts, err := rlp.EncodeToBytes(&receipts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{ReceiptHash: types.DeriveSha(types.Receipts{})}
+
+	req := &ReceiptsRequest{Header: header, Rlp: rlpReceipts}
+	if !req.Valid() {
+		t.Fatal("expected receipts matching the header's receipt root to be valid")
+	}
+
+	tampered := &ReceiptsRequest{Header: header, Rlp: []byte("not rlp receipts")}
+	if tampered.Valid() {
+		t.Fatal("expected receipts not matching the header's receipt root to be rejected")
+	}
+}
+
+func TestCodeRequestValid(t *testing.T) {
+	code := []byte{0x60, 0x00, 0x60, 0x00}
+	hash := crypto.Keccak256Hash(code)
+
+	req := &CodeRequest{Hash: hash, Code: code}
+	if !req.Valid() {
+		t.Fatal("expected code matching its own hash to be valid")
+	}
+
+	tampered := &CodeRequest{Hash: hash, Code: []byte{0x00}}
+	if tampered.Valid() {
+		t.Fatal("expected code not matching the requested hash to be rejected")
+	}
+}
+
+func TestTrieRequestValid(t *testing.T) {
+	node := []byte("a raw trie node")
+	key := crypto.Keccak256(node)
+
+	req := &TrieRequest{Keys: [][]byte{key}, Nodes: [][]byte{node}}
+	if !req.Valid() {
+		t.Fatal("expected a node matching its own hash to be valid")
+	}
+
+	tampered := &TrieRequest{Keys: [][]byte{key}, Nodes: [][]byte{[]byte("a different node")}}
+	if tampered.Valid() {
+		t.Fatal("expected a node not matching the requested hash to be rejected")
+	}
+}
@@ -0,0 +1,39 @@
+package light
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// LightChain serves blocks and receipts without a full state, fetching
+// whatever the local database is missing through an Odr scheduler.
+type LightChain struct {
+	db     ethdb.Database
+	access *core.Access
+}
+
+// NewLightChain wires up a LightChain that falls back to odr for anything
+// the local database doesn't already have.
+func NewLightChain(db ethdb.Database, odr *Odr) *LightChain {
+	return &LightChain{
+		db:     db,
+		access: core.NewFallBackAccess(odr),
+	}
+}
+
+// GetBlock retrieves the block with the given hash, fetching and verifying
+// its body through odr if it isn't stored locally.
+func (self *LightChain) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return self.access.GetBlock(ctx, self.db, hash)
+}
+
+// GetBlockReceipts retrieves the receipts for the block with the given
+// hash, fetching and verifying them through odr if they aren't stored
+// locally.
+func (self *LightChain) GetBlockReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return self.access.GetBlockReceipts(ctx, self.db, hash)
+}
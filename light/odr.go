@@ -0,0 +1,155 @@
+package light
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// ErrNoPeers is returned when a request is issued while no LES peer is
+// connected to serve it.
+var ErrNoPeers = errors.New("no light peers available")
+
+// requestTimeout bounds how long the scheduler waits for a single peer to
+// answer before it is considered non-responsive and the request is sent
+// again to whichever peer is then least loaded.
+const requestTimeout = 8 * time.Second
+
+// maxInflightPerPeer caps the number of outstanding ODR requests the
+// scheduler will hand to any one peer, so a single slow peer can't starve
+// the rest of the queue.
+const maxInflightPerPeer = 16
+
+// Odr is a core.OdrBackend that multiplexes outstanding requests across a
+// pool of LES peers, retrying when the chosen one times out or disconnects.
+// Each retry goes to the least-loaded registered peer, which can be the one
+// that just failed; failed peers are not excluded.
+type Odr struct {
+	lock  sync.Mutex
+	peers map[string]Peer
+	// inflight tracks, per peer id, how many requests are currently
+	// outstanding so Retrieve can pick the least loaded peer.
+	inflight map[string]int
+
+	nextReqID uint64
+	pending   map[uint64]chan core.OdrRequest
+}
+
+// NewOdr creates an empty scheduler; peers register themselves with
+// RegisterPeer/UnregisterPeer as they connect and disconnect.
+func NewOdr() *Odr {
+	return &Odr{
+		peers:    make(map[string]Peer),
+		inflight: make(map[string]int),
+		pending:  make(map[uint64]chan core.OdrRequest),
+	}
+}
+
+// RegisterPeer makes p a candidate for future requests.
+func (self *Odr) RegisterPeer(p Peer) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.peers[p.Id()] = p
+}
+
+// UnregisterPeer drops p from the candidate set; any request currently
+// assigned to it is left for Retrieve's retry loop to reassign.
+func (self *Odr) UnregisterPeer(p Peer) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.peers, p.Id())
+	delete(self.inflight, p.Id())
+}
+
+// Deliver is called by the LES protocol handler when a response for reqID
+// arrives; it wakes up the matching Retrieve call.
+func (self *Odr) Deliver(reqID uint64, resp core.OdrRequest) {
+	self.lock.Lock()
+	ch, ok := self.pending[reqID]
+	if ok {
+		delete(self.pending, reqID)
+	}
+	self.lock.Unlock()
+	if ok {
+		ch <- resp
+	}
+}
+
+// Retrieve implements core.OdrBackend. It keeps picking the least-loaded
+// peer and waiting up to requestTimeout for a valid response, picking a
+// peer again on send failure, timeout, invalid response or disconnect, until
+// ctx is cancelled or no peer remains. The new pick may be the same peer.
+func (self *Odr) Retrieve(ctx context.Context, req core.OdrRequest) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		peer, err := self.selectPeer()
+		if err != nil {
+			return err
+		}
+
+		reqID := self.reserve(peer)
+		respCh := make(chan core.OdrRequest, 1)
+		self.lock.Lock()
+		self.pending[reqID] = respCh
+		self.lock.Unlock()
+
+		err = peer.Send(reqID, req)
+		if err == nil {
+			select {
+			case resp := <-respCh:
+				if resp.Valid() {
+					self.release(peer, reqID)
+					return nil
+				}
+				// fall through to retry on another peer
+			case <-peer.Disconnected():
+			case <-time.After(requestTimeout):
+			case <-ctx.Done():
+				self.release(peer, reqID)
+				return ctx.Err()
+			}
+		}
+		self.release(peer, reqID)
+	}
+}
+
+// selectPeer returns the candidate peer with the fewest outstanding
+// requests, skipping any at maxInflightPerPeer.
+func (self *Odr) selectPeer() (Peer, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	var best Peer
+	bestLoad := maxInflightPerPeer
+	for id, p := range self.peers {
+		if load := self.inflight[id]; load < bestLoad {
+			best, bestLoad = p, load
+		}
+	}
+	if best == nil {
+		return nil, ErrNoPeers
+	}
+	return best, nil
+}
+
+func (self *Odr) reserve(p Peer) uint64 {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.inflight[p.Id()]++
+	self.nextReqID++
+	return self.nextReqID
+}
+
+func (self *Odr) release(p Peer, reqID uint64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.pending, reqID)
+	if self.inflight[p.Id()] > 0 {
+		self.inflight[p.Id()]--
+	}
+}
@@ -0,0 +1,23 @@
+// Package light implements an on-demand retrieval backend on top of a
+// LES-style peer set, and the light.Trie/LightChain consumers built on it.
+package light
+
+import (
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// Peer is the subset of a LES peer the odr scheduler needs. The concrete
+// implementation (les.peer) also tracks protocol version, head and total
+// difficulty, none of which the scheduler cares about.
+type Peer interface {
+	// Id uniquely identifies the peer for inflight/limit bookkeeping.
+	Id() string
+	// Send dispatches reqID's wire-protocol request (chosen from req's
+	// concrete type) to the peer; the response is later handed back to the
+	// scheduler through Deliver.
+	Send(reqID uint64, req core.OdrRequest) error
+	// Disconnected is closed when the peer drops, so inflight requests
+	// assigned to it can be retried elsewhere without waiting for a
+	// timeout.
+	Disconnected() <-chan struct{}
+}
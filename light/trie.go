@@ -0,0 +1,59 @@
+package light
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Trie resolves any node missing from the local database through an Access
+// configured with an Odr backend instead of returning
+// trie.MissingNodeError, so a light client can read state the same way a
+// full node does, modulo the extra network round trip. TryGet satisfies
+// the standard trie.Trie interface directly (via context.Background());
+// TryGetContext is the same lookup with an explicit, cancellable context
+// for callers that have one to thread through.
+type Trie struct {
+	access *core.Access
+	db     ethdb.Database
+	trie   *trie.Trie
+	root   common.Hash
+}
+
+// NewTrie opens the trie rooted at root, fetching it node by node from odr
+// as paths are resolved rather than requiring it to be present locally.
+func NewTrie(root common.Hash, db ethdb.Database, odr *Odr) (*Trie, error) {
+	access := core.NewFallBackAccess(odr)
+	t, err := trie.New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	return &Trie{access: access, db: db, trie: t, root: root}, nil
+}
+
+// TryGet returns the value for key using context.Background(), so a Trie
+// satisfies the standard trie.Trie interface.
+func (self *Trie) TryGet(key []byte) ([]byte, error) {
+	return self.TryGetContext(context.Background(), key)
+}
+
+// TryGetContext returns the value for key, fetching and verifying whatever
+// trie nodes the local database is missing along the way, bailing out
+// early if ctx is cancelled.
+func (self *Trie) TryGetContext(ctx context.Context, key []byte) ([]byte, error) {
+	for {
+		value, err := self.trie.TryGet(key)
+		missing, ok := err.(*trie.MissingNodeError)
+		if !ok {
+			return value, err
+		}
+		if _, ferr := self.access.GetTrieEntry(ctx, self.db, self.root, missing.NodeHash[:]); ferr != nil {
+			return nil, ferr
+		}
+		// The missing node has been fetched into db; retry the local trie
+		// walk, which will now get further before hitting the next gap.
+	}
+}
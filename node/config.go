@@ -0,0 +1,156 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/nat"
+)
+
+// Config holds the settings a Node is constructed with: everything needed
+// to stand up its p2p networking layer and its RPC/IPC/WS endpoints, plus
+// whatever a registered service needs from ServiceContext.
+type Config struct {
+	// Name sets the node's base name used in the devp2p node identifier.
+	Name string
+	// PrivateKey is the node's identity; a random key is generated if nil.
+	PrivateKey *ecdsa.PrivateKey
+
+	// DataDir is the filesystem folder used for persistent storage, the
+	// node key, static/trusted node lists and the peer database. An empty
+	// DataDir means purely ephemeral, in-memory operation.
+	DataDir string
+
+	// Discovery enables the discv4 UDP discovery protocol.
+	Discovery bool
+	// NAT configures port mapping for the listening socket.
+	NAT nat.Interface
+	// NoDial disables dialing out to discovered/static peers; the node
+	// then only accepts inbound connections.
+	NoDial bool
+	// BootstrapNodes seed the discovery table on startup.
+	BootstrapNodes []*discover.Node
+	// StaticNodes are always kept connected, read from
+	// DataDir/static-nodes.json.
+	StaticNodes []*discover.Node
+	// TrustedNodes bypass the peer limit, read from
+	// DataDir/trusted-nodes.json.
+	TrustedNodes []*discover.Node
+	// ListenAddr is the TCP/UDP address the node listens on for p2p
+	// connections.
+	ListenAddr string
+	// MaxPeers bounds the number of concurrent p2p connections.
+	MaxPeers int
+
+	// IPCPath is the filename (relative to DataDir, unless absolute) of
+	// the IPC socket/pipe exposing the collected RPC APIs. Empty disables
+	// the IPC endpoint.
+	IPCPath string
+
+	// HTTPHost, left empty, disables the HTTP-RPC endpoint.
+	HTTPHost    string
+	HTTPPort    int
+	HTTPCors    string
+	HTTPModules []string
+
+	// WSHost, left empty, disables the WebSocket-RPC endpoint.
+	WSHost    string
+	WSPort    int
+	WSOrigins string
+	WSModules []string
+}
+
+// NodeDB resolves the path of the persistent discovery node database: a
+// file under DataDir, or the in-memory sentinel when the node has no data
+// directory (matching p2p.Server.NodeDatabase's own convention).
+func (c *Config) NodeDB() string {
+	if c.DataDir == "" {
+		return "" // ephemeral, in-memory
+	}
+	return filepath.Join(c.DataDir, "nodes")
+}
+
+// IPCEndpoint resolves IPCPath into the platform-specific endpoint string
+// p2p/rpc's IPC listener expects: a named pipe path on Windows, a filesystem
+// path (relative to DataDir unless absolute) everywhere else. An empty
+// IPCPath disables the IPC endpoint altogether.
+func (c *Config) IPCEndpoint() string {
+	if c.IPCPath == "" {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		if filepath.Base(c.IPCPath) == c.IPCPath {
+			return `\\.\pipe\` + c.IPCPath
+		}
+		return c.IPCPath
+	}
+	if filepath.IsAbs(c.IPCPath) {
+		return c.IPCPath
+	}
+	return filepath.Join(c.DataDir, c.IPCPath)
+}
+
+// resolvePath joins DataDir and a relative path, or returns path unchanged
+// if it's empty or already absolute.
+func (c *Config) resolvePath(path string) string {
+	if path == "" || filepath.IsAbs(path) || c.DataDir == "" {
+		return path
+	}
+	return filepath.Join(c.DataDir, path)
+}
+
+// parseNodes reads a JSON array of enode:// URLs from DataDir/file. A
+// missing file is not an error: it simply yields no nodes, since
+// static-nodes.json/trusted-nodes.json are both optional.
+func (c *Config) parseNodes(file string) []*discover.Node {
+	path := c.resolvePath(file)
+	if c.DataDir == "" || path == "" {
+		return nil
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.V(logger.Error).Infof("can't read %s: %v", path, err)
+		}
+		return nil
+	}
+	var urls []string
+	if err := json.Unmarshal(blob, &urls); err != nil {
+		glog.V(logger.Error).Infof("can't parse %s: %v", path, err)
+		return nil
+	}
+	nodes := make([]*discover.Node, 0, len(urls))
+	for _, url := range urls {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			glog.V(logger.Error).Infof("invalid node URL %s in %s: %v", url, path, err)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
@@ -18,11 +18,16 @@
 package node
 
 import (
-	"crypto/ecdsa"
 	"errors"
+	"fmt"
+	"reflect"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/logger"
+	"github.com/ethereum/go-ethereum/logger/glog"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var (
@@ -34,138 +39,293 @@ var (
 
 // Node represents a P2P node into which arbitrary services might be registered.
 type Node struct {
-	config *p2p.Server                        // Configuration of the underlying P2P networking layer
-	stack  map[string]func() (Service, error) // Protocol stack registered into this node
+	config       *Config
+	serverConfig p2p.Server // Template the live P2P server is copied from on every Start
 
-	running  *p2p.Server        // Currently running P2P networking layer
-	services map[string]Service // Currently running services
+	serviceFuncs []ServiceConstructor // Constructors, in the order they were registered
+
+	running     *p2p.Server // Currently running P2P networking layer, nil when stopped
+	ipcHandler  *rpc.Server // Currently running IPC RPC server exposing every service's APIs
+	httpHandler *rpc.Server // Currently running HTTP RPC server, nil if HTTPHost is unset
+	wsHandler   *rpc.Server // Currently running WebSocket RPC server, nil if WSHost is unset
+	services    []Service   // Currently running services, in registration order
 
 	lock sync.RWMutex
 }
 
-// New creates a new P2P node, ready for protocol registration.
-func New(key *ecdsa.PrivateKey, name string) *Node {
+// New creates a new P2P node from conf, ready for protocol registration. A
+// private key is generated if conf.PrivateKey is nil.
+func New(conf *Config) (*Node, error) {
+	key := conf.PrivateKey
+	if key == nil {
+		var err error
+		key, err = crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.StaticNodes == nil {
+		conf.StaticNodes = conf.parseNodes("static-nodes.json")
+	}
+	if conf.TrustedNodes == nil {
+		conf.TrustedNodes = conf.parseNodes("trusted-nodes.json")
+	}
 	return &Node{
-		config: &p2p.Server{
-			PrivateKey: key,
-			Name:       name,
-			/*Discovery:      config.Discovery,
-			Protocols:      protocols,
-			NAT:            config.NAT,
-			NoDial:         !config.Dial,
-			BootstrapNodes: config.parseBootNodes(),
-			StaticNodes:    config.parseNodes(staticNodes),
-			TrustedNodes:   config.parseNodes(trustedNodes),
-			NodeDatabase:   nodeDb,*/
+		config: conf,
+		serverConfig: p2p.Server{
+			PrivateKey:     key,
+			Name:           conf.Name,
+			Discovery:      conf.Discovery,
+			NAT:            conf.NAT,
+			NoDial:         conf.NoDial,
+			BootstrapNodes: conf.BootstrapNodes,
+			StaticNodes:    conf.StaticNodes,
+			TrustedNodes:   conf.TrustedNodes,
+			NodeDatabase:   conf.NodeDB(),
+			ListenAddr:     conf.ListenAddr,
+			MaxPeers:       conf.MaxPeers,
 		},
-		stack: make(map[string]func() (Service, error)),
-	}
+	}, nil
 }
 
-// Register injects a new service into the node's stack.
-func (n *Node) Register(id string, constructor func() (Service, error)) error {
+// Register injects a new service constructor into the node's stack. The
+// constructor is not called until Start, at which point it runs in
+// registration order with a ServiceContext that can already resolve every
+// service registered ahead of it.
+func (n *Node) Register(constructor ServiceConstructor) error {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
-	// Short circuit if the node is running or if the id is taken
-	if n.running != nil {
+	if n.isRunning() {
 		return ErrNodeRunning
 	}
-	if _, ok := n.stack[id]; ok {
-		return ErrServiceRegistered
-	}
-	// Otherwise register the service and return
-	n.stack[id] = constructor
-
+	n.serviceFuncs = append(n.serviceFuncs, constructor)
 	return nil
 }
 
-// Unregister removes a service from a node's stack. If the node is currently
-// running, an error will be returned.
-func (n *Node) Unregister(id string) error {
-	n.lock.Lock()
-	defer n.lock.Unlock()
-
-	// Short circuit if the node is running, or if the service is unknown
-	if n.running != nil {
-		return ErrNodeRunning
-	}
-	if _, ok := n.stack[id]; !ok {
-		return ErrServiceUnknown
-	}
-	// Otherwise drop the service and return
-	delete(n.stack, id)
-	return nil
+// isRunning reports whether the node's p2p server is live; it must be
+// called with n.lock held.
+func (n *Node) isRunning() bool {
+	return n.running != nil && n.running.Running()
 }
 
-// Start create a live P2P node and starts running it.
+// Start constructs every registered service (in registration order, so
+// later services can depend on earlier ones), merges their p2p protocols
+// into the P2P server, starts it, then starts the IPC/HTTP/WS servers
+// exposing every service's RPC APIs.
 func (n *Node) Start() error {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
-	// Short circuit if the node's already running
-	if n.running != nil {
+	if n.isRunning() {
 		return ErrNodeRunning
 	}
-	// Otherwise copy and specialize the P2P configuration
-	running := new(p2p.Server)
-	*running = *n.config
 
-	services := make(map[string]Service)
-	for id, constructor := range n.stack {
-		service, err := constructor()
+	ctx := &ServiceContext{
+		services: make(map[reflect.Type]Service),
+		config:   n.config,
+	}
+	services := make([]Service, 0, len(n.serviceFuncs))
+	for _, constructor := range n.serviceFuncs {
+		service, err := constructor(ctx)
 		if err != nil {
 			return err
 		}
-		services[id] = service
+		kind := reflect.TypeOf(service)
+		if _, ok := ctx.services[kind]; ok {
+			return ErrServiceRegistered
+		}
+		ctx.services[kind] = service
+		services = append(services, service)
+	}
+
+	// Copy the template into a fresh server for this run and merge every
+	// service's p2p protocols into it before starting; copying rather than
+	// reusing n.running across Start calls is what keeps a Restart from
+	// accumulating every service's protocols a second time.
+	running := new(p2p.Server)
+	*running = n.serverConfig
+
+	apis := []rpc.API{}
+	for _, service := range services {
+		running.Protocols = append(running.Protocols, service.Protocols()...)
+		apis = append(apis, service.APIs()...)
 	}
-	// Start the freshly assembled P2P server
 	if err := running.Start(); err != nil {
 		return err
 	}
-	// Start each of the services
-	started := []string{}
-	for id, service := range services {
-		// Start the next service, stopping all previous upon failure
-		if err := service.Start(); err != nil {
-			for _, id := range started {
-				services[id].Stop()
+
+	if err := n.startIPC(apis); err != nil {
+		running.Stop()
+		return err
+	}
+	if err := n.startHTTP(apis); err != nil {
+		n.stopIPC()
+		running.Stop()
+		return err
+	}
+	if err := n.startWS(apis); err != nil {
+		n.stopHTTP()
+		n.stopIPC()
+		running.Stop()
+		return err
+	}
+
+	// Start each of the services, in registration order, tearing down
+	// whatever already started if one of them fails.
+	started := []Service{}
+	for _, service := range services {
+		if err := service.Start(running); err != nil {
+			for i := len(started) - 1; i >= 0; i-- {
+				started[i].Stop()
 			}
+			n.stopWS()
+			n.stopHTTP()
+			n.stopIPC()
+			running.Stop()
 			return err
 		}
-		// Mark the service started for potential cleanup
-		started = append(started, id)
+		started = append(started, service)
 	}
-	// Finish initializing the startup
 	n.services = services
 	n.running = running
 
 	return nil
 }
 
-// Stop terminates a running node along with all it's services. In the node was
-// not started, an error is returned.
+// startIPC starts the IPC server exposing apis, unless the node has no
+// IPC endpoint configured.
+func (n *Node) startIPC(apis []rpc.API) error {
+	endpoint := n.config.IPCEndpoint()
+	if endpoint == "" {
+		return nil
+	}
+	server := rpc.NewServer()
+	for _, api := range apis {
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+			return err
+		}
+	}
+	listener, err := rpc.CreateIPCListener(endpoint)
+	if err != nil {
+		return err
+	}
+	go server.ServeListener(listener)
+	glog.V(logger.Info).Infof("IPC endpoint opened: %s", endpoint)
+
+	n.ipcHandler = server
+	return nil
+}
+
+func (n *Node) stopIPC() {
+	if n.ipcHandler != nil {
+		n.ipcHandler.Stop()
+		n.ipcHandler = nil
+	}
+}
+
+// startHTTP starts the JSON-RPC-over-HTTP server exposing apis whose
+// namespace is allow-listed in config.HTTPModules, unless HTTPHost is unset.
+func (n *Node) startHTTP(apis []rpc.API) error {
+	if n.config.HTTPHost == "" {
+		return nil
+	}
+	server := rpc.NewServer()
+	for _, api := range apis {
+		if !moduleAllowed(api.Namespace, n.config.HTTPModules) {
+			continue
+		}
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+			return err
+		}
+	}
+	if err := rpc.StartHTTPEndpoint(n.httpEndpoint(), server, n.config.HTTPCors); err != nil {
+		return err
+	}
+	glog.V(logger.Info).Infof("HTTP endpoint opened: http://%s", n.httpEndpoint())
+
+	n.httpHandler = server
+	return nil
+}
+
+func (n *Node) stopHTTP() {
+	if n.httpHandler != nil {
+		n.httpHandler.Stop()
+		n.httpHandler = nil
+	}
+}
+
+// startWS starts the JSON-RPC-over-WebSocket server exposing apis whose
+// namespace is allow-listed in config.WSModules, unless WSHost is unset.
+func (n *Node) startWS(apis []rpc.API) error {
+	if n.config.WSHost == "" {
+		return nil
+	}
+	server := rpc.NewServer()
+	for _, api := range apis {
+		if !moduleAllowed(api.Namespace, n.config.WSModules) {
+			continue
+		}
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+			return err
+		}
+	}
+	if err := rpc.StartWSEndpoint(n.wsEndpoint(), server, n.config.WSOrigins); err != nil {
+		return err
+	}
+	glog.V(logger.Info).Infof("WebSocket endpoint opened: ws://%s", n.wsEndpoint())
+
+	n.wsHandler = server
+	return nil
+}
+
+func (n *Node) stopWS() {
+	if n.wsHandler != nil {
+		n.wsHandler.Stop()
+		n.wsHandler = nil
+	}
+}
+
+// moduleAllowed reports whether namespace should be exposed given an
+// explicit module allow-list; an empty list allows everything.
+func moduleAllowed(namespace string, modules []string) bool {
+	if len(modules) == 0 {
+		return true
+	}
+	for _, module := range modules {
+		if module == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop terminates a running node along with all it's services, in reverse
+// registration order so a service never outlives what it depends on. In the
+// node was not started, an error is returned.
 func (n *Node) Stop() error {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
-	// Short circuit if the node's not running
-	if n.running == nil {
+	if !n.isRunning() {
 		return ErrNodeStopped
 	}
-	// Otherwise terminate all the services and the P2P server too
 	failure := &StopError{
-		Services: make(map[string]error),
+		Services: make(map[reflect.Type]error),
 	}
-	for id, service := range n.services {
+	for i := len(n.services) - 1; i >= 0; i-- {
+		service := n.services[i]
 		if err := service.Stop(); err != nil {
-			failure.Services[id] = err
+			failure.Services[reflect.TypeOf(service)] = err
 		}
 	}
+	n.stopWS()
+	n.stopHTTP()
+	n.stopIPC()
 	n.running.Stop()
+	n.running = nil
 
 	n.services = nil
-	n.running = nil
 
 	if len(failure.Services) > 0 {
 		return failure
@@ -184,3 +344,35 @@ func (n *Node) Restart() error {
 	}
 	return nil
 }
+
+// Server returns the underlying p2p.Server so a registered service (e.g. a
+// light-client fetcher) can reach the network without relying on global
+// state. It returns nil if the node is not running.
+func (n *Node) Server() *p2p.Server {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	if !n.isRunning() {
+		return nil
+	}
+	return n.running
+}
+
+// DataDir returns the filesystem folder the node was configured with.
+func (n *Node) DataDir() string {
+	return n.config.DataDir
+}
+
+// IPCEndpoint returns the IPC endpoint this node exposes its RPC APIs on,
+// or the empty string if the IPC endpoint is disabled.
+func (n *Node) IPCEndpoint() string {
+	return n.config.IPCEndpoint()
+}
+
+func (n *Node) httpEndpoint() string {
+	return fmt.Sprintf("%s:%d", n.config.HTTPHost, n.config.HTTPPort)
+}
+
+func (n *Node) wsEndpoint() string {
+	return fmt.Sprintf("%s:%d", n.config.WSHost, n.config.WSPort)
+}
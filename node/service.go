@@ -0,0 +1,82 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ServiceContext is handed to a ServiceConstructor so it can look up the
+// services registered ahead of it (dependency injection) and reach whatever
+// of the node's own state it needs (data directory, and so on).
+type ServiceContext struct {
+	services map[reflect.Type]Service // Already constructed services, keyed by their concrete type
+	config   *Config
+}
+
+// Service retrieves an already constructed service registered earlier on
+// the same node and stores it into the memory addressed by dependency,
+// which must be a pointer to a variable of the service's concrete type:
+// services are keyed by their exact type, so a pointer to an interface they
+// implement does not match. It returns an error wrapping ErrServiceUnknown
+// if no service of that type is registered, and panics if dependency is not
+// a pointer.
+func (ctx *ServiceContext) Service(dependency interface{}) error {
+	element := reflect.ValueOf(dependency).Elem()
+	if service, ok := ctx.services[element.Type()]; ok {
+		element.Set(reflect.ValueOf(service))
+		return nil
+	}
+	return fmt.Errorf("%v: %w", element.Type(), ErrServiceUnknown)
+}
+
+// DataDir retrieves the data directory the node was configured with, so a
+// service can place its own persistent state alongside the others.
+func (ctx *ServiceContext) DataDir() string {
+	return ctx.config.DataDir
+}
+
+// ServiceConstructor builds a service out of the node's context. It is
+// called once per Node.Start, after every constructor registered ahead of
+// it has already run, so it can pull in its dependencies through
+// ServiceContext.Service.
+type ServiceConstructor func(ctx *ServiceContext) (Service, error)
+
+// Service is implemented by all services a node.Node can run. In addition
+// to the Start/Stop lifecycle, a service contributes whatever p2p protocols
+// and RPC APIs it wants the rest of the node to expose.
+type Service interface {
+	// Protocols returns the p2p protocols this service wishes to run; they
+	// are merged with every other service's before the p2p.Server starts.
+	Protocols() []p2p.Protocol
+
+	// APIs returns the collection of RPC descriptors this service offers,
+	// merged into the node's RPC/IPC/WS servers.
+	APIs() []rpc.API
+
+	// Start spins up the service's goroutines, given the live p2p.Server
+	// (useful for looking up the local node's identity or dialing peers).
+	Start(server *p2p.Server) error
+
+	// Stop terminates all goroutines belonging to the service, blocking
+	// until they have shut down.
+	Stop() error
+}
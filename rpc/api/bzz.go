@@ -18,6 +18,7 @@ package api
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/bzz"
 	"github.com/ethereum/go-ethereum/common"
@@ -39,6 +40,10 @@ type bzzApi struct {
 	swarm    *bzz.Swarm
 	methods  map[string]bzzhandler
 	codec    codec.ApiCoder
+
+	streamLock sync.Mutex
+	streams    map[string]*bzzStream
+	nextStream uint64
 }
 
 // eth callback handler
@@ -46,13 +51,18 @@ type bzzhandler func(*bzzApi, *shared.Request) (interface{}, error)
 
 var (
 	bzzMapping = map[string]bzzhandler{
-		"bzz_register": (*bzzApi).Register,
-		"bzz_resolve":  (*bzzApi).Resolve,
-		"bzz_download": (*bzzApi).Download,
-		"bzz_upload":   (*bzzApi).Upload,
-		"bzz_get":      (*bzzApi).Get,
-		"bzz_put":      (*bzzApi).Put,
-		"bzz_modify":   (*bzzApi).Modify,
+		"bzz_register":    (*bzzApi).Register,
+		"bzz_resolve":     (*bzzApi).Resolve,
+		"bzz_download":    (*bzzApi).Download,
+		"bzz_upload":      (*bzzApi).Upload,
+		"bzz_get":         (*bzzApi).Get,
+		"bzz_put":         (*bzzApi).Put,
+		"bzz_modify":      (*bzzApi).Modify,
+		"bzz_getStream":   (*bzzApi).GetStream,
+		"bzz_putStream":   (*bzzApi).PutStream,
+		"bzz_readChunk":   (*bzzApi).ReadChunk,
+		"bzz_writeChunk":  (*bzzApi).WriteChunk,
+		"bzz_closeStream": (*bzzApi).CloseStream,
 	}
 )
 
@@ -62,7 +72,14 @@ func newSwarmOfflineError(method string) error {
 
 // create new bzzApi instance
 func NewBzzApi(xeth *xeth.XEth, eth *eth.Ethereum, codec codec.Codec) *bzzApi {
-	return &bzzApi{xeth, eth, eth.Swarm, bzzMapping, codec.New(nil)}
+	return &bzzApi{
+		xeth:     xeth,
+		ethereum: eth,
+		swarm:    eth.Swarm,
+		methods:  bzzMapping,
+		codec:    codec.New(nil),
+		streams:  make(map[string]*bzzStream),
+	}
 }
 
 // collection with supported methods
@@ -210,4 +227,4 @@ func (self *bzzApi) Modify(req *shared.Request) (interface{}, error) {
 	}
 
 	return s.Api().Modify(args.RootHash, args.Path, args.ContentHash, args.ContentType)
-}
\ No newline at end of file
+}
@@ -0,0 +1,321 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with go-ethereum.  If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc/shared"
+)
+
+// BzzGetStreamArgs are the arguments to bzz_getStream: path is the swarm
+// path (bzz://... or a raw manifest hash) to open for streamed reading.
+type BzzGetStreamArgs struct {
+	Path string
+}
+
+// BzzPutStreamArgs are the arguments to bzz_putStream: contentType is
+// stored alongside the uploaded content the same way bzz_put does.
+type BzzPutStreamArgs struct {
+	ContentType string
+}
+
+// BzzReadChunkArgs are the arguments to bzz_readChunk: streamId is the id
+// returned by bzz_getStream, offset/len bound the slice of the stream to
+// read back.
+type BzzReadChunkArgs struct {
+	StreamId string
+	Offset   int64
+	Len      int
+}
+
+// BzzWriteChunkArgs are the arguments to bzz_writeChunk: streamId is the id
+// returned by bzz_putStream, data is base64-encoded content to append.
+type BzzWriteChunkArgs struct {
+	StreamId string
+	Data     string
+}
+
+// BzzCloseStreamArgs are the arguments to bzz_closeStream: streamId is the
+// id returned by bzz_getStream/bzz_putStream to release.
+type BzzCloseStreamArgs struct {
+	StreamId string
+}
+
+// maxOpenStreams bounds how many streams a single connection's bzzApi may
+// have open at once, so a client can't exhaust server memory by opening
+// bzz_getStream/bzz_putStream requests without ever closing them.
+const maxOpenStreams = 64
+
+// maxChunkLen bounds a single bzz_readChunk response, so a malformed or
+// hostile "len" argument can't force an oversized allocation.
+const maxChunkLen = 4 * 1024 * 1024
+
+// streamIdleTimeout aborts a bzz_putStream that nobody writes to or closes
+// for this long, so a client that opens a stream and disappears doesn't
+// leak the chunker goroutine PutStream started for it forever.
+const streamIdleTimeout = 5 * time.Minute
+
+var errStreamAborted = errors.New("stream idle for too long, aborted")
+
+// bzzStream is either the read side of a bzz_getStream or the write side of
+// a bzz_putStream; exactly one of reader/writer is set. put is resolved
+// once CloseStream has flushed the upload and swarm has returned the final
+// manifest hash. lock serializes ReadChunk/WriteChunk/CloseStream calls
+// against the same stream id so a close can never run concurrently with
+// the read/write it's meant to follow.
+type bzzStream struct {
+	lock   sync.Mutex
+	reader io.ReadCloser
+	writer io.WriteCloser
+	put    chan putResult
+	idle   *time.Timer
+}
+
+type putResult struct {
+	hash string
+	err  error
+}
+
+// GetStream opens path for reading without buffering its content, and
+// returns an opaque stream id for bzz_readChunk/bzz_closeStream to address
+// it by.
+func (self *bzzApi) GetStream(req *shared.Request) (interface{}, error) {
+	s := self.swarm
+	if s == nil {
+		return nil, newSwarmOfflineError(req.Method)
+	}
+
+	args := new(BzzGetStreamArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+
+	reader, _, _, _, err := s.Api().GetReader(args.Path)
+	if err != nil {
+		return nil, err
+	}
+	return self.newStream(&bzzStream{reader: reader})
+}
+
+// armIdleTimeout (re)starts id's idle timer; once it fires with no
+// intervening WriteChunk/CloseStream call, the stream is aborted and its
+// chunker goroutine released.
+func (self *bzzApi) armIdleTimeout(id string, stream *bzzStream) {
+	if stream.idle != nil {
+		stream.idle.Stop()
+	}
+	stream.idle = time.AfterFunc(streamIdleTimeout, func() { self.abortStream(id) })
+}
+
+// abortStream force-closes a stream left open past streamIdleTimeout,
+// unblocking whatever goroutine PutStream/GetStream left waiting on it.
+func (self *bzzApi) abortStream(id string) {
+	self.streamLock.Lock()
+	stream, ok := self.streams[id]
+	if ok {
+		delete(self.streams, id)
+	}
+	self.streamLock.Unlock()
+	if !ok {
+		return
+	}
+
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+	if stream.reader != nil {
+		stream.reader.Close()
+	}
+	if stream.writer != nil {
+		if pw, ok := stream.writer.(*io.PipeWriter); ok {
+			pw.CloseWithError(errStreamAborted)
+		} else {
+			stream.writer.Close()
+		}
+	}
+}
+
+// PutStream opens a new upload of the given content type and returns an
+// opaque stream id for bzz_writeChunk/bzz_closeStream to address it by; the
+// manifest hash is only available once CloseStream returns.
+func (self *bzzApi) PutStream(req *shared.Request) (interface{}, error) {
+	s := self.swarm
+	if s == nil {
+		return nil, newSwarmOfflineError(req.Method)
+	}
+
+	args := new(BzzPutStreamArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+
+	pr, pw := io.Pipe()
+	result := make(chan putResult, 1)
+	go func() {
+		hash, err := s.Api().PutReader(pr, args.ContentType)
+		pr.CloseWithError(err)
+		result <- putResult{hash, err}
+	}()
+	id, err := self.newStream(&bzzStream{writer: pw, put: result})
+	if err != nil {
+		pw.CloseWithError(err)
+		return "", err
+	}
+	self.streamLock.Lock()
+	self.armIdleTimeout(id, self.streams[id])
+	self.streamLock.Unlock()
+	return id, nil
+}
+
+// ReadChunk reads up to len bytes at offset from an open bzz_getStream.
+// Sequential offsets are the expected usage; swarm's chunk reader seeks
+// internally so out-of-order reads work too, just without the sequential
+// read-ahead swarm's HTTP server relies on for throughput.
+func (self *bzzApi) ReadChunk(req *shared.Request) (interface{}, error) {
+	args := new(BzzReadChunkArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+
+	if args.Len <= 0 || args.Len > maxChunkLen {
+		return nil, fmt.Errorf("len must be between 1 and %d, got %d", maxChunkLen, args.Len)
+	}
+
+	stream, err := self.getStream(args.StreamId)
+	if err != nil {
+		return nil, err
+	}
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	if stream.reader == nil {
+		return nil, fmt.Errorf("stream %s is not readable", args.StreamId)
+	}
+	if seeker, ok := stream.reader.(io.Seeker); ok {
+		if _, err := seeker.Seek(args.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	buf := make([]byte, args.Len)
+	n, err := io.ReadFull(stream.reader, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"data": base64.StdEncoding.EncodeToString(buf[:n]),
+		"eof":  err == io.EOF || err == io.ErrUnexpectedEOF,
+	}, nil
+}
+
+// WriteChunk appends base64-encoded data to an open bzz_putStream. The
+// underlying io.Pipe blocks this call until the chunker on the other end
+// has read all of this chunk's data, which is the backpressure that keeps a
+// fast client from buffering an entire multi-GB upload in memory here.
+func (self *bzzApi) WriteChunk(req *shared.Request) (interface{}, error) {
+	args := new(BzzWriteChunkArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+
+	stream, err := self.getStream(args.StreamId)
+	if err != nil {
+		return nil, err
+	}
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	if stream.writer == nil {
+		return nil, fmt.Errorf("stream %s is not writable", args.StreamId)
+	}
+	data, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+	_, err = stream.writer.Write(data)
+	if err == nil {
+		self.armIdleTimeout(args.StreamId, stream)
+	}
+	return err == nil, err
+}
+
+// CloseStream releases a stream's slot in the table. For a bzz_putStream it
+// first closes the pipe's write end and waits for the chunker goroutine
+// started by PutStream to finish, returning the resulting manifest hash.
+func (self *bzzApi) CloseStream(req *shared.Request) (interface{}, error) {
+	args := new(BzzCloseStreamArgs)
+	if err := self.codec.Decode(req.Params, &args); err != nil {
+		return nil, shared.NewDecodeParamError(err.Error())
+	}
+
+	stream, err := self.dropStream(args.StreamId)
+	if err != nil {
+		return nil, err
+	}
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+
+	if stream.idle != nil {
+		stream.idle.Stop()
+	}
+	if stream.reader != nil {
+		return true, stream.reader.Close()
+	}
+	stream.writer.Close()
+	result := <-stream.put
+	return result.hash, result.err
+}
+
+func (self *bzzApi) newStream(stream *bzzStream) (string, error) {
+	self.streamLock.Lock()
+	defer self.streamLock.Unlock()
+
+	if len(self.streams) >= maxOpenStreams {
+		return "", fmt.Errorf("too many open streams (max %d)", maxOpenStreams)
+	}
+	self.nextStream++
+	id := fmt.Sprintf("%x", self.nextStream)
+	self.streams[id] = stream
+	return id, nil
+}
+
+func (self *bzzApi) getStream(id string) (*bzzStream, error) {
+	self.streamLock.Lock()
+	defer self.streamLock.Unlock()
+
+	stream, ok := self.streams[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown stream %s", id)
+	}
+	return stream, nil
+}
+
+func (self *bzzApi) dropStream(id string) (*bzzStream, error) {
+	self.streamLock.Lock()
+	defer self.streamLock.Unlock()
+
+	stream, ok := self.streams[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown stream %s", id)
+	}
+	delete(self.streams, id)
+	return stream, nil
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// These tests drive bzzStream/newStream/getStream/dropStream/abortStream
+// directly rather than through GetStream/PutStream/WriteChunk/CloseStream:
+// those RPC entry points decode req.Params via rpc/codec and, for
+// PutStream, call into a live *bzz.Swarm, neither of which this tree
+// vendors. Exercising the stream table and io.Pipe wiring they share still
+// covers the locking/timer/goroutine-handoff logic the request introduced.
+
+func newTestBzzApi() *bzzApi {
+	return &bzzApi{streams: make(map[string]*bzzStream)}
+}
+
+// TestStreamWriteCloseRoundTrip stands in for a bzz_putStream upload:
+// WriteChunk's job is writing base64-decoded bytes into stream.writer, and
+// CloseStream's is closing it and waiting on stream.put for the chunker
+// goroutine's result, same as PutStream would start over s.Api().PutReader.
+func TestStreamWriteCloseRoundTrip(t *testing.T) {
+	self := newTestBzzApi()
+
+	pr, pw := io.Pipe()
+	result := make(chan putResult, 1)
+	go func() {
+		data, err := ioutil.ReadAll(pr)
+		if err != nil {
+			result <- putResult{err: err}
+			return
+		}
+		result <- putResult{hash: string(data)}
+	}()
+
+	id, err := self.newStream(&bzzStream{writer: pw, put: result})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := self.getStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := base64.StdEncoding.DecodeString(base64.StdEncoding.EncodeToString([]byte("hello swarm")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.lock.Lock()
+	if _, err := stream.writer.Write(data); err != nil {
+		t.Fatalf("write chunk: %v", err)
+	}
+	self.armIdleTimeout(id, stream)
+	stream.lock.Unlock()
+
+	closed, err := self.dropStream(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.lock.Lock()
+	if closed.idle != nil {
+		closed.idle.Stop()
+	}
+	closed.writer.Close()
+	res := <-closed.put
+	closed.lock.Unlock()
+
+	if res.err != nil {
+		t.Fatalf("close stream: %v", res.err)
+	}
+	if res.hash != "hello swarm" {
+		t.Fatalf("expected the bytes written to come back as the put result, got %q", res.hash)
+	}
+	if _, err := self.getStream(id); err == nil {
+		t.Fatal("expected the stream to be dropped from the table after close")
+	}
+}
+
+// TestAbortStreamIdle exercises abortStream's force-close path directly,
+// standing in for the idle timer firing after streamIdleTimeout.
+func TestAbortStreamIdle(t *testing.T) {
+	self := newTestBzzApi()
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	result := make(chan putResult, 1)
+
+	id, err := self.newStream(&bzzStream{writer: pw, put: result})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	self.abortStream(id)
+
+	if _, err := pr.Read(make([]byte, 1)); err != errStreamAborted {
+		t.Fatalf("expected the chunker's read after abort to fail with errStreamAborted, got %v", err)
+	}
+	if _, err := self.getStream(id); err == nil {
+		t.Fatal("expected the stream to be dropped from the table once aborted")
+	}
+}